@@ -0,0 +1,200 @@
+package main
+
+import (
+	"sync"
+)
+
+const (
+	SUBSCRIBER_BUFFER_SIZE = 32
+	MAX_SUBSCRIBER_DROPS   = 5
+)
+
+// CancelFunc unsubscribes a subscriber and releases its channel.
+type CancelFunc func()
+
+// SubscriberIdentity identifies who is subscribing, so the broker can scope
+// events to a user and suppress echoing an event back to the client that
+// originated it.
+type SubscriberIdentity struct {
+	UserID   string
+	ClientID string
+}
+
+// subscriber is a single topic subscription: a bounded channel plus a
+// count of consecutive sends that found no room even after the oldest
+// buffered event was dropped (see deliverLocked). closed records whether ch has already been
+// closed, so callers holding the Broker's lock can tell without racing a
+// second close(ch) against a pending send.
+type subscriber struct {
+	ch       chan Event
+	drops    int
+	identity SubscriberIdentity
+	closed   bool
+}
+
+// wants reports whether event should be delivered to sub: the event must be
+// a broadcast (no TargetUserID) or addressed to sub's user, and sub must not
+// be the client that originated it.
+func (sub *subscriber) wants(event Event) bool {
+	if event.TargetUserID != "" && event.TargetUserID != sub.identity.UserID {
+		return false
+	}
+	if event.OriginClientID != "" && event.OriginClientID == sub.identity.ClientID {
+		return false
+	}
+	return true
+}
+
+// Broker fans events published to a topic out to every subscriber of that
+// topic. Publishers never block on a slow subscriber: once a subscriber's
+// buffer is full the broker drops the oldest buffered event to make room
+// (a diode). A slow subscriber loses its oldest events this way; it is not
+// disconnected for it.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*subscriber]struct{}
+	published   int64
+	dispatch    typedDispatcher
+}
+
+// NewBroker returns an empty Broker ready to accept subscriptions.
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[string]map[*subscriber]struct{})}
+}
+
+// Subscribe registers a new subscriber on topic under identity and replays
+// any history events newer than lastEventID (that identity is allowed to
+// see) onto its channel. It returns a channel of events and a CancelFunc
+// that must be called to unsubscribe.
+func (b *Broker) Subscribe(topic string, lastEventID int, identity SubscriberIdentity) (<-chan Event, CancelFunc) {
+	sub := &subscriber{ch: make(chan Event, SUBSCRIBER_BUFFER_SIZE), identity: identity}
+
+	// Fetch replay history before taking the lock (it may hit disk on the
+	// file-backed EventStore), then register the subscriber and deliver
+	// its replay under a single critical section so a concurrent
+	// CloseAll/cancel can never close sub.ch while a replay send for it
+	// is still in flight.
+	missed := getMissedEvents(lastEventID)
+
+	b.mu.Lock()
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[*subscriber]struct{})
+	}
+	b.subscribers[topic][sub] = struct{}{}
+
+	for _, event := range missed {
+		if sub.wants(event) {
+			b.deliverLocked(topic, sub, event)
+		}
+	}
+	b.mu.Unlock()
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if sub.closed {
+			return
+		}
+		if _, ok := b.subscribers[topic][sub]; !ok {
+			return
+		}
+		delete(b.subscribers[topic], sub)
+		sub.closed = true
+		close(sub.ch)
+	}
+
+	return sub.ch, cancel
+}
+
+// Publish records event in history and fans it out to every subscriber of
+// topic. A subscriber whose buffer is full has its oldest event dropped to
+// make room.
+func (b *Broker) Publish(topic string, event Event) {
+	addEventToHistory(event)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.published++
+
+	for sub := range b.subscribers[topic] {
+		if !sub.wants(event) {
+			continue
+		}
+		b.deliverLocked(topic, sub, event)
+	}
+}
+
+// deliverLocked sends event to sub, dropping the oldest buffered event to
+// make room if sub's buffer is already full (never blocking the caller).
+// Dropping the oldest event does not count towards MAX_SUBSCRIBER_DROPS:
+// sub is disconnected only after that many retries in a row find no room,
+// and since every send to sub.ch happens under b.mu the retry after the
+// drop always finds room. Callers must hold b.mu, which is also what guards sub.closed and
+// close(sub.ch), so a send here can never race a concurrent cancel/CloseAll.
+func (b *Broker) deliverLocked(topic string, sub *subscriber, event Event) {
+	if sub.closed {
+		return
+	}
+
+	select {
+	case sub.ch <- event:
+		sub.drops = 0
+		return
+	default:
+	}
+
+	// Buffer full: drop the oldest queued event and retry once.
+	select {
+	case <-sub.ch:
+	default:
+	}
+
+	select {
+	case sub.ch <- event:
+		sub.drops = 0
+	default:
+		sub.drops++
+		if sub.drops >= MAX_SUBSCRIBER_DROPS {
+			delete(b.subscribers[topic], sub)
+			sub.closed = true
+			close(sub.ch)
+		}
+	}
+}
+
+// SubscriberCounts returns the number of active subscribers per topic.
+func (b *Broker) SubscriberCounts() map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	counts := make(map[string]int, len(b.subscribers))
+	for topic, subs := range b.subscribers {
+		counts[topic] = len(subs)
+	}
+	return counts
+}
+
+// PublishedCount returns the total number of events published across all
+// topics since the broker was created.
+func (b *Broker) PublishedCount() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.published
+}
+
+// CloseAll unsubscribes and closes every active subscriber's channel on
+// every topic. It is intended for server shutdown: each closed channel
+// causes the handler goroutine reading it to observe the close and return.
+func (b *Broker) CloseAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for topic, subs := range b.subscribers {
+		for sub := range subs {
+			sub.closed = true
+			close(sub.ch)
+		}
+		delete(b.subscribers, topic)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBrokerOriginSuppression verifies that an event published with
+// OriginClientID set is delivered to other subscribers on the topic but
+// suppressed for the subscriber whose ClientID originated it.
+func TestBrokerOriginSuppression(t *testing.T) {
+	// Subscribe's replay reads the package-level eventStore regardless of
+	// which Broker instance is used, so a fresh Broker still needs a fresh
+	// store to avoid replaying history left behind by another test.
+	eventStore = NewMemoryEventStore()
+	broker := NewBroker()
+
+	chA, cancelA := broker.Subscribe("events", 0, SubscriberIdentity{ClientID: "client-a"})
+	defer cancelA()
+	chB, cancelB := broker.Subscribe("events", 0, SubscriberIdentity{ClientID: "client-b"})
+	defer cancelB()
+
+	broker.Publish("events", Event{
+		ID:             1,
+		Type:           "update",
+		Data:           "hello",
+		OriginClientID: "client-a",
+		Timestamp:      time.Now().UTC(),
+	})
+
+	select {
+	case event := <-chB:
+		if event.ID != 1 {
+			t.Fatalf("client B got event ID %d, want 1", event.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("client B (not the originator) never received the event")
+	}
+
+	select {
+	case event, ok := <-chA:
+		if ok {
+			t.Fatalf("client A received its own event back: %+v", event)
+		}
+	case <-time.After(100 * time.Millisecond):
+		// No event arrived for client A in the window; that's the expected
+		// outcome since it originated the event.
+	}
+}
+
+// TestBrokerTargetUserScoping verifies that an event published with
+// TargetUserID set is delivered to the subscriber addressed to that user
+// but not to a subscriber identified as a different user.
+func TestBrokerTargetUserScoping(t *testing.T) {
+	eventStore = NewMemoryEventStore()
+	broker := NewBroker()
+
+	chMatch, cancelMatch := broker.Subscribe("notifications", 0, SubscriberIdentity{UserID: "user123"})
+	defer cancelMatch()
+	chOther, cancelOther := broker.Subscribe("notifications", 0, SubscriberIdentity{UserID: "user456"})
+	defer cancelOther()
+
+	broker.Publish("notifications", Event{
+		ID:           1,
+		Type:         "notification",
+		Data:         "hello",
+		TargetUserID: "user123",
+		Timestamp:    time.Now().UTC(),
+	})
+
+	select {
+	case event := <-chMatch:
+		if event.ID != 1 {
+			t.Fatalf("matching user got event ID %d, want 1", event.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber for the targeted user never received the event")
+	}
+
+	select {
+	case event, ok := <-chOther:
+		if ok {
+			t.Fatalf("subscriber for a different user received the event: %+v", event)
+		}
+	case <-time.After(100 * time.Millisecond):
+		// No event arrived for the other user in the window; that's the
+		// expected outcome since the event was scoped to a different user.
+	}
+}
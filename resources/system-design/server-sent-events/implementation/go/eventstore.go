@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventStore persists published events and serves Last-Event-ID replay.
+// Implementations must be safe for concurrent use.
+type EventStore interface {
+	// Append persists event.
+	Append(event Event) error
+	// Since returns every stored event with ID greater than lastID.
+	Since(lastID int) ([]Event, error)
+	// Truncate discards events older than maxAge.
+	Truncate(maxAge time.Duration) error
+}
+
+// memoryEventStore is an in-memory ring buffer capped at MAX_HISTORY_SIZE
+// events. History does not survive a restart.
+type memoryEventStore struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewMemoryEventStore returns an empty in-memory EventStore.
+func NewMemoryEventStore() *memoryEventStore {
+	return &memoryEventStore{}
+}
+
+func (s *memoryEventStore) Append(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	if len(s.events) > MAX_HISTORY_SIZE {
+		s.events = s.events[1:]
+	}
+	return nil
+}
+
+func (s *memoryEventStore) Since(lastID int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var missed []Event
+	for _, e := range s.events {
+		if e.ID > lastID {
+			missed = append(missed, e)
+		}
+	}
+	return missed, nil
+}
+
+func (s *memoryEventStore) Truncate(maxAge time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := time.Now().Add(-maxAge)
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if e.Timestamp.After(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	s.events = kept
+	return nil
+}
+
+// fileEventStore is an append-only, newline-delimited JSON log on disk, one
+// event per line. Since replays the log from the start, so Last-Event-ID
+// replay (and the ID counter derived from it) survives a server restart.
+type fileEventStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileEventStore opens (creating if necessary) the log file at path.
+func NewFileEventStore(path string) (*fileEventStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &fileEventStore{path: path}, nil
+}
+
+func (s *fileEventStore) Append(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *fileEventStore) Since(lastID int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.readAllLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	var missed []Event
+	for _, e := range events {
+		if e.ID > lastID {
+			missed = append(missed, e)
+		}
+	}
+	return missed, nil
+}
+
+func (s *fileEventStore) Truncate(maxAge time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.readAllLocked()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cutoff := time.Now().Add(-maxAge)
+	w := bufio.NewWriter(f)
+	for _, e := range events {
+		if !e.Timestamp.After(cutoff) {
+			continue
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// readAllLocked reads and parses every event currently in the log. Callers
+// must hold s.mu.
+func (s *fileEventStore) readAllLocked() ([]Event, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,215 @@
+// Command eventgen generates the Broker's typed callback API.
+//
+// It scans the Go source files in the package directory for struct types
+// annotated with an "sse:event" directive comment:
+//
+//	//sse:event type="update" topic="events"
+//	type UpdatePayload struct { ... }
+//
+// For every annotated struct it emits, on the Broker, an On<Name> method to
+// register a typed callback and an Emit<Name> method that publishes the
+// event on the given topic and invokes every registered callback, where
+// <Name> is the struct name with its "Payload" suffix trimmed. Adding a new
+// event type is therefore one struct declaration plus a directive comment
+// followed by `go generate ./...`.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var directiveRE = regexp.MustCompile(`sse:event\s+type="([^"]+)"\s+topic="([^"]+)"`)
+
+// payloadType describes one annotated payload struct.
+type payloadType struct {
+	Struct  string // e.g. "UpdatePayload"
+	Name    string // e.g. "Update"
+	Type    string // SSE event type, e.g. "update"
+	Topic   string // broker topic, e.g. "events"
+	Article string // "a" or "an", agreeing with Type
+}
+
+// article returns the English indefinite article that agrees with word,
+// e.g. "a \"update\" event" reads wrong but "an \"update\" event" doesn't.
+func article(word string) string {
+	if word == "" {
+		return "a"
+	}
+	switch word[0] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return "an"
+	default:
+		return "a"
+	}
+}
+
+func main() {
+	out := flag.String("out", "broker_callbacks_gen.go", "output file, relative to the package directory")
+	flag.Parse()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("eventgen: %v", err)
+	}
+
+	payloads, err := findPayloadTypes(dir)
+	if err != nil {
+		log.Fatalf("eventgen: %v", err)
+	}
+	if len(payloads) == 0 {
+		log.Fatalf("eventgen: no //sse:event annotated types found in %s", dir)
+	}
+
+	var buf strings.Builder
+	if err := genTemplate.Execute(&buf, payloads); err != nil {
+		log.Fatalf("eventgen: %v", err)
+	}
+
+	src, err := format.Source([]byte(buf.String()))
+	if err != nil {
+		log.Fatalf("eventgen: formatting generated source: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, *out), src, 0644); err != nil {
+		log.Fatalf("eventgen: %v", err)
+	}
+}
+
+// findPayloadTypes parses every *.go file directly in dir (skipping
+// generated output and the internal/ generator itself) and returns the
+// structs annotated with an sse:event directive, sorted by Name.
+func findPayloadTypes(dir string) ([]payloadType, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads []payloadType
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_gen.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", file, err)
+		}
+
+		for _, decl := range f.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				if _, ok := typeSpec.Type.(*ast.StructType); !ok {
+					continue
+				}
+
+				doc := genDecl.Doc
+				if doc == nil {
+					doc = typeSpec.Doc
+				}
+				if doc == nil {
+					continue
+				}
+
+				// Scan the raw comment lines rather than doc.Text(): Text()
+				// strips lines that look like tool directives (which is
+				// exactly the shape of our "sse:event ..." line), so it
+				// never contains the directive we're looking for.
+				var match []string
+				for _, c := range doc.List {
+					if m := directiveRE.FindStringSubmatch(c.Text); m != nil {
+						match = m
+						break
+					}
+				}
+				if match == nil {
+					continue
+				}
+
+				payloads = append(payloads, payloadType{
+					Struct:  typeSpec.Name.Name,
+					Name:    strings.TrimSuffix(typeSpec.Name.Name, "Payload"),
+					Type:    match[1],
+					Topic:   match[2],
+					Article: article(match[1]),
+				})
+			}
+		}
+	}
+
+	sort.Slice(payloads, func(i, j int) bool { return payloads[i].Name < payloads[j].Name })
+	return payloads, nil
+}
+
+var genTemplate = template.Must(template.New("callbacks").Funcs(template.FuncMap{
+	"lower": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToLower(s[:1]) + s[1:]
+	},
+}).Parse(`// Code generated by eventgen; DO NOT EDIT.
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// typedDispatcher holds the typed callbacks registered via the generated
+// On<Name> methods below. Broker holds it in its dispatch field.
+type typedDispatcher struct {
+	mu sync.Mutex
+
+{{- range .}}
+	{{.Name | lower}}Callbacks []func({{.Struct}})
+{{- end}}
+}
+{{range .}}
+// On{{.Name}} registers callback to run whenever {{.Article}} {{printf "%q" .Type}} event
+// is emitted.
+func (b *Broker) On{{.Name}}(callback func({{.Struct}})) {
+	b.dispatch.mu.Lock()
+	defer b.dispatch.mu.Unlock()
+	b.dispatch.{{.Name | lower}}Callbacks = append(b.dispatch.{{.Name | lower}}Callbacks, callback)
+}
+
+// Emit{{.Name}} publishes {{.Article}} {{printf "%q" .Type}} event on the {{printf "%q" .Topic}} topic
+// and invokes every callback registered with On{{.Name}}.
+func (b *Broker) Emit{{.Name}}(payload {{.Struct}}) {
+	b.Publish({{printf "%q" .Topic}}, Event{
+		ID:        getNextEventID(),
+		Type:      {{printf "%q" .Type}},
+		Data:      payload,
+		Timestamp: time.Now().UTC(),
+	})
+
+	b.dispatch.mu.Lock()
+	callbacks := append([]func({{.Struct}}){}, b.dispatch.{{.Name | lower}}Callbacks...)
+	b.dispatch.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(payload)
+	}
+}
+{{end}}`))
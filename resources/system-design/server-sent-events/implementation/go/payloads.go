@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+//go:generate go run ./internal/eventgen -out broker_callbacks_gen.go
+
+// UpdatePayload is the payload of a generic "update" event, published on
+// the "events" topic.
+//
+//sse:event type="update" topic="events"
+type UpdatePayload struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     int       `json:"value"`
+	Message   string    `json:"message"`
+}
+
+// NotificationPayload is the payload of a "notification" event, published
+// on the "notifications" topic.
+//
+//sse:event type="notification" topic="notifications"
+type NotificationPayload struct {
+	UserID    string    `json:"user_id"`
+	Type      string    `json:"type"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// StockTickPayload is the payload of a "price_update" event, published on
+// the "stocks" topic.
+//
+//sse:event type="price_update" topic="stocks"
+type StockTickPayload struct {
+	Symbol    string    `json:"symbol"`
+	Price     float64   `json:"price"`
+	Change    float64   `json:"change"`
+	Timestamp time.Time `json:"timestamp"`
+}
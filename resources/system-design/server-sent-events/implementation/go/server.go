@@ -7,6 +7,8 @@ Demonstrates:
 - Multiple event types.
 - Automatic reconnection with Last-Event-ID.
 - Keep-alive heartbeat.
+- A shared Broker so multiple clients see the same event stream.
+- Typed event payloads with generated per-type callback dispatch.
 
 Note:
 This implementation serves to illustrate SSE concepts and is not production ready.
@@ -14,38 +16,59 @@ This implementation serves to illustrate SSE concepts and is not production read
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"math"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 )
 
 const (
-	PORT              = 8000
-	MAX_HISTORY_SIZE  = 100
-	EVENT_SEND_DELAY  = 2 * time.Second
-	NOTIF_SEND_DELAY  = 5 * time.Second
-	STOCK_SEND_DELAY  = 1 * time.Second
-	HEARTBEAT_INTERVAL = 15
+	PORT               = 8000
+	MAX_HISTORY_SIZE   = 100
+	EVENT_SEND_DELAY   = 2 * time.Second
+	NOTIF_SEND_DELAY   = 5 * time.Second
+	STOCK_SEND_DELAY   = 1 * time.Second
+	HEARTBEAT_INTERVAL = 15 * time.Second
 )
 
-// Event represents an SSE event.
+// Event represents an SSE event. TargetUserID scopes an event to a single
+// user (empty means broadcast to every subscriber of the topic), and
+// OriginClientID identifies the client that caused the event so the broker
+// can skip delivering it back to its own originator.
+//
+// Data stays interface{} rather than a per-type envelope: the wire format
+// is the bare payload JSON on the "data:" line (see UpdatePayload and
+// friends in payloads.go), and Type is already the discriminator a reader
+// needs to know which concrete struct to decode it as. The generated
+// EmitX/OnX API in broker_callbacks_gen.go is what gives callers an
+// actually-typed view of a payload, both when publishing and via the
+// typed callback hooks registered through logEmittedEvents below.
 type Event struct {
-	ID   int         `json:"id"`
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	ID             int         `json:"id"`
+	Type           string      `json:"type"`
+	Data           interface{} `json:"data"`
+	TargetUserID   string      `json:"target_user_id,omitempty"`
+	OriginClientID string      `json:"origin_client_id,omitempty"`
+	Timestamp      time.Time   `json:"timestamp"`
 }
 
 // Global state
 var (
-	eventHistory   []Event
 	eventIDCounter int
 	mu             sync.Mutex
+
+	broker     = NewBroker()
+	eventStore EventStore = NewMemoryEventStore()
 )
 
 // getNextEventID returns the next event ID and increments the counter.
@@ -56,27 +79,22 @@ func getNextEventID() int {
 	return eventIDCounter
 }
 
-// addEventToHistory adds an event to history, maintaining max size.
+// addEventToHistory persists an event to the configured EventStore.
 func addEventToHistory(event Event) {
-	mu.Lock()
-	defer mu.Unlock()
-	eventHistory = append(eventHistory, event)
-	if len(eventHistory) > MAX_HISTORY_SIZE {
-		eventHistory = eventHistory[1:]
+	if err := eventStore.Append(event); err != nil {
+		log.Printf("event store: append failed: %v", err)
 	}
 }
 
-// getMissedEvents returns events with ID greater than lastID.
+// getMissedEvents returns events with ID greater than lastID from the
+// configured EventStore.
 func getMissedEvents(lastID int) []Event {
-	mu.Lock()
-	defer mu.Unlock()
-	var missed []Event
-	for _, e := range eventHistory {
-		if e.ID > lastID {
-			missed = append(missed, e)
-		}
+	events, err := eventStore.Since(lastID)
+	if err != nil {
+		log.Printf("event store: since failed: %v", err)
+		return nil
 	}
-	return missed
+	return events
 }
 
 // writeSSEEvent writes an SSE event to the response writer.
@@ -98,121 +116,124 @@ func writeSSEHeartbeat(w http.ResponseWriter) {
 	w.(http.Flusher).Flush()
 }
 
-// eventGenerator generates the event stream.
-func eventGenerator(w http.ResponseWriter, lastEventID string) {
+// clientIDFromRequest returns the caller's self-reported client identity,
+// read from the X-Client-Unique-Id header or, failing that, the
+// client_id query parameter.
+func clientIDFromRequest(r *http.Request) string {
+	if clientID := r.Header.Get("X-Client-Unique-Id"); clientID != "" {
+		return clientID
+	}
+	return r.URL.Query().Get("client_id")
+}
+
+// streamTopic is the common handler body for every SSE endpoint: it sends
+// a "connected" event, subscribes to topic on the broker under identity
+// (replaying any events missed since Last-Event-ID), and then streams
+// subscriber events to w until the subscription ends, interleaving
+// periodic heartbeats.
+func streamTopic(w http.ResponseWriter, r *http.Request, topic string, identity SubscriberIdentity, connectedData interface{}) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
-	// Send connection established event
 	id := getNextEventID()
-	writeSSEEvent(w, id, "connected", map[string]string{
-		"message": "Connected to SSE stream.",
-	})
+	writeSSEEvent(w, id, "connected", connectedData)
 
-	// Replay missed events if Last-Event-ID provided
-	if lastEventID != "" {
-		if lastID, err := strconv.Atoi(lastEventID); err == nil {
-			for _, event := range getMissedEvents(lastID) {
-				writeSSEEvent(w, event.ID, event.Type, event.Data)
-			}
+	lastID := 0
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		if parsed, err := strconv.Atoi(lastEventID); err == nil {
+			lastID = parsed
 		}
 	}
 
-	// Continuous event stream
-	ticker := time.NewTicker(EVENT_SEND_DELAY)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		id := getNextEventID()
-		eventData := map[string]interface{}{
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
-			"value":     rand.Intn(100) + 1,
-			"message":   fmt.Sprintf("Update #%d.", id),
-		}
-
-		event := Event{ID: id, Type: "update", Data: eventData}
-		addEventToHistory(event)
+	events, cancel := broker.Subscribe(topic, lastID, identity)
+	defer cancel()
 
-		writeSSEEvent(w, id, "update", eventData)
+	heartbeat := time.NewTicker(HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
 
-		// Periodic heartbeat
-		if id%HEARTBEAT_INTERVAL == 0 {
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			writeSSEEvent(w, event.ID, event.Type, event.Data)
+		case <-heartbeat.C:
 			writeSSEHeartbeat(w)
+		case <-r.Context().Done():
+			// Client disconnected; stop so defer cancel() unsubscribes us
+			// instead of leaking this goroutine on a dead ResponseWriter.
+			// (Server shutdown ends the loop through broker.CloseAll
+			// closing events instead.)
+			return
 		}
 	}
 }
 
-// notificationGenerator generates user-specific notifications.
-func notificationGenerator(w http.ResponseWriter, userID string) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+// publishEvents publishes a generic "update" event to the "events" topic
+// on every tick.
+func publishEvents() {
+	ticker := time.NewTicker(EVENT_SEND_DELAY)
+	defer ticker.Stop()
 
-	// Send connection established event
-	id := getNextEventID()
-	writeSSEEvent(w, id, "connected", map[string]string{
-		"user_id": userID,
-		"message": "Connected.",
-	})
+	for range ticker.C {
+		broker.EmitUpdate(UpdatePayload{
+			Timestamp: time.Now().UTC(),
+			Value:     rand.Intn(100) + 1,
+			Message:   "Periodic update.",
+		})
+	}
+}
 
+// demoUserIDs simulates notifications arriving for several different users
+// on the shared "notifications" topic.
+var demoUserIDs = []string{"user123", "user456", "user789"}
+
+// publishNotifications publishes a "notification" event to the
+// "notifications" topic on every tick.
+func publishNotifications() {
 	notificationTypes := []string{"message", "alert", "info"}
 	ticker := time.NewTicker(NOTIF_SEND_DELAY)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		id := getNextEventID()
-		notification := map[string]interface{}{
-			"user_id":   userID,
-			"type":      notificationTypes[rand.Intn(len(notificationTypes))],
-			"content":   fmt.Sprintf("Notification at %s.", time.Now().UTC().Format(time.RFC3339)),
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
-		}
-
-		writeSSEEvent(w, id, "notification", notification)
+		now := time.Now().UTC()
+		broker.EmitNotification(NotificationPayload{
+			UserID:    demoUserIDs[rand.Intn(len(demoUserIDs))],
+			Type:      notificationTypes[rand.Intn(len(notificationTypes))],
+			Content:   fmt.Sprintf("Notification at %s.", now.Format(time.RFC3339)),
+			Timestamp: now,
+		})
 	}
 }
 
-// stockTickerGenerator simulates stock price updates.
-func stockTickerGenerator(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
-	// Send connection established event
-	id := getNextEventID()
-	writeSSEEvent(w, id, "connected", map[string]string{
-		"message": "Connected to SSE stream.",
-	})
-
+// publishStockTicks publishes a "price_update" event to the "stocks" topic
+// on every tick, maintaining one shared price per symbol so that every
+// subscriber observes the same sequence of prices.
+func publishStockTicks() {
 	stocks := map[string]float64{
 		"AAPL":  150.00,
 		"GOOGL": 2800.00,
 		"MSFT":  300.00,
 	}
+	symbols := []string{"AAPL", "GOOGL", "MSFT"}
 
 	ticker := time.NewTicker(STOCK_SEND_DELAY)
 	defer ticker.Stop()
 
-	symbols := []string{"AAPL", "GOOGL", "MSFT"}
-
 	for range ticker.C {
-		id := getNextEventID()
-
-		// Random price change
 		symbol := symbols[rand.Intn(len(symbols))]
 		change := (rand.Float64() * 10) - 5
 		stocks[symbol] += change
 
-		data := map[string]interface{}{
-			"symbol":    symbol,
-			"price":     math.Round(stocks[symbol]*100) / 100,
-			"change":    math.Round(change*100) / 100,
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
-		}
-
-		writeSSEEvent(w, id, "price_update", data)
+		broker.EmitStockTick(StockTickPayload{
+			Symbol:    symbol,
+			Price:     math.Round(stocks[symbol]*100) / 100,
+			Change:    math.Round(change*100) / 100,
+			Timestamp: time.Now().UTC(),
+		})
 	}
 }
 
@@ -220,8 +241,10 @@ func stockTickerGenerator(w http.ResponseWriter) {
 
 // /events endpoint with event replay support
 func handleEvents(w http.ResponseWriter, r *http.Request) {
-	lastEventID := r.Header.Get("Last-Event-ID")
-	eventGenerator(w, lastEventID)
+	identity := SubscriberIdentity{ClientID: clientIDFromRequest(r)}
+	streamTopic(w, r, "events", identity, map[string]string{
+		"message": "Connected to SSE stream.",
+	})
 }
 
 // /notifications endpoint for user-specific notifications
@@ -230,12 +253,60 @@ func handleNotifications(w http.ResponseWriter, r *http.Request) {
 	if userID == "" {
 		userID = "user123"
 	}
-	notificationGenerator(w, userID)
+	identity := SubscriberIdentity{UserID: userID, ClientID: clientIDFromRequest(r)}
+	streamTopic(w, r, "notifications", identity, map[string]string{
+		"user_id": userID,
+		"message": "Connected.",
+	})
 }
 
 // /stocks endpoint for real-time stock price updates
 func handleStocks(w http.ResponseWriter, r *http.Request) {
-	stockTickerGenerator(w)
+	identity := SubscriberIdentity{ClientID: clientIDFromRequest(r)}
+	streamTopic(w, r, "stocks", identity, map[string]string{
+		"message": "Connected to SSE stream.",
+	})
+}
+
+// publishRequest is the JSON body accepted by /publish.
+type publishRequest struct {
+	Topic          string      `json:"topic"`
+	UserID         string      `json:"user_id"`
+	OriginClientID string      `json:"origin_client_id"`
+	Type           string      `json:"type"`
+	Data           interface{} `json:"data"`
+}
+
+// /publish lets external callers inject an event into a topic, optionally
+// scoped to a user and tagged with the client that originated it.
+func handlePublish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req publishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Topic == "" || req.Type == "" {
+		http.Error(w, "topic and type are required", http.StatusBadRequest)
+		return
+	}
+
+	id := getNextEventID()
+	broker.Publish(req.Topic, Event{
+		ID:             id,
+		Type:           req.Type,
+		Data:           req.Data,
+		TargetUserID:   req.UserID,
+		OriginClientID: req.OriginClientID,
+		Timestamp:      time.Now().UTC(),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id})
 }
 
 // ============= HTTP Endpoints =============
@@ -257,70 +328,70 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 	</head>
 	<body>
 		<h1>Server-Sent Events Test Client</h1>
-		
+
 		<div class="controls">
 			<button onclick="connectEvents()">Connect to Events</button>
 			<button onclick="connectNotifications()">Connect to Notifications</button>
 			<button onclick="connectStocks()">Connect to Stocks</button>
 			<button onclick="disconnect()">Disconnect</button>
 		</div>
-		
+
 		<div>
 			<h3>Event Stream:</h3>
 			<div id="events"></div>
 		</div>
-		
+
 		<script>
 			let eventSource = null;
-			
+
 			function connectEvents() {
 				disconnect();
 				eventSource = new EventSource('/events');
 				setupEventSource();
 			}
-			
+
 			function connectNotifications() {
 				disconnect();
 				eventSource = new EventSource('/notifications?user_id=user123');
 				setupEventSource();
 			}
-			
+
 			function connectStocks() {
 				disconnect();
 				eventSource = new EventSource('/stocks');
 				setupEventSource();
 			}
-			
+
 			function setupEventSource() {
 				eventSource.onopen = () => {
 					addEvent('Connection', 'Connected', 'green');
 				};
-				
+
 				eventSource.onerror = () => {
 					addEvent('Error', 'Connection error', 'red');
 				};
-				
+
 				// Listen to all event types
 				eventSource.addEventListener('connected', (e) => {
 					addEvent('Connected', e.data, 'green');
 				});
-				
+
 				eventSource.addEventListener('update', (e) => {
 					const data = JSON.parse(e.data);
 					addEvent('Update', 'Value: ' + data.value, 'blue');
 				});
-				
+
 				eventSource.addEventListener('notification', (e) => {
 					const data = JSON.parse(e.data);
 					addEvent('Notification', data.content, 'orange');
 				});
-				
+
 				eventSource.addEventListener('price_update', (e) => {
 					const data = JSON.parse(e.data);
 					addEvent('Stock', data.symbol + ': $' + data.price, 'purple');
 				});
 			}
-			
+
 			function disconnect() {
 				if (eventSource) {
 					eventSource.close();
@@ -328,7 +399,7 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 					eventSource = null;
 				}
 			}
-			
+
 			function addEvent(type, message, color) {
 				const events = document.getElementById('events');
 				const div = document.createElement('div');
@@ -336,7 +407,7 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 				div.style.borderLeft = '4px solid ' + color;
 				div.innerHTML = '<strong>[' + type + ']</strong> ' + message + ' <small>(' + new Date().toLocaleTimeString() + ')</small>';
 				events.insertBefore(div, events.firstChild);
-				
+
 				// Keep only last 50 events
 				while (events.children.length > 50) {
 					events.removeChild(events.lastChild);
@@ -352,29 +423,117 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 
 // /health endpoint for health checks
 func handleHealth(w http.ResponseWriter, r *http.Request) {
-	mu.Lock()
-	activeEvents := len(eventHistory)
-	mu.Unlock()
+	activeEvents := len(getMissedEvents(0))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":         "healthy",
-		"active_events":  activeEvents,
-		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+		"status":        "healthy",
+		"active_events": activeEvents,
+		"timestamp":     time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// /metrics reports current subscriber count per topic and total events
+// published, so operators can confirm goroutines actually drain on client
+// disconnect rather than leaking.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subscribers_by_topic": broker.SubscriberCounts(),
+		"events_published":     broker.PublishedCount(),
+		"timestamp":            time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+var (
+	storeBackend = flag.String("event-store", "memory", "event history backend: memory or file")
+	storePath    = flag.String("event-store-path", "events.log", "log file path (file backend only)")
+)
+
+// recoverEventIDCounter replays the store's full history and advances
+// eventIDCounter past the highest ID found, so IDs keep increasing across a
+// restart instead of colliding with events already in the store.
+func recoverEventIDCounter() {
+	events, err := eventStore.Since(0)
+	if err != nil {
+		log.Fatalf("event store: failed to recover history: %v", err)
+	}
+	for _, e := range events {
+		if e.ID > eventIDCounter {
+			eventIDCounter = e.ID
+		}
+	}
+}
+
+// logEmittedEvents registers the generated typed callback hooks as a
+// process-wide observability log: every EmitX call invokes the matching
+// On<Name> callbacks regardless of how many (if any) SSE subscribers are
+// currently listening, so this runs independently of streamTopic's
+// per-connection fan-out.
+func logEmittedEvents() {
+	broker.OnUpdate(func(p UpdatePayload) {
+		log.Printf("emit update: value=%d message=%q", p.Value, p.Message)
+	})
+	broker.OnNotification(func(p NotificationPayload) {
+		log.Printf("emit notification: user_id=%s type=%s", p.UserID, p.Type)
+	})
+	broker.OnStockTick(func(p StockTickPayload) {
+		log.Printf("emit price_update: symbol=%s price=%.2f", p.Symbol, p.Price)
 	})
 }
 
 func main() {
+	flag.Parse()
 	rand.Seed(time.Now().UnixNano())
 
+	switch *storeBackend {
+	case "file":
+		store, err := NewFileEventStore(*storePath)
+		if err != nil {
+			log.Fatalf("event store: failed to open %q: %v", *storePath, err)
+		}
+		eventStore = store
+	case "memory":
+		eventStore = NewMemoryEventStore()
+	default:
+		log.Fatalf("event store: unknown backend %q (want memory or file)", *storeBackend)
+	}
+	recoverEventIDCounter()
+	logEmittedEvents()
+
+	go publishEvents()
+	go publishNotifications()
+	go publishStockTicks()
+
 	http.HandleFunc("/events", handleEvents)
 	http.HandleFunc("/notifications", handleNotifications)
 	http.HandleFunc("/stocks", handleStocks)
+	http.HandleFunc("/publish", handlePublish)
 	http.HandleFunc("/", handleIndex)
 	http.HandleFunc("/health", handleHealth)
+	http.HandleFunc("/metrics", handleMetrics)
 
 	addr := fmt.Sprintf(":%d", PORT)
+	srv := &http.Server{Addr: addr}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Printf("shutdown signal received, draining connections...\n")
+
+		broker.CloseAll()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("server shutdown: %v\n", err)
+		}
+	}()
+
 	log.Printf("SSE server starting...\n")
 	log.Printf("Test client: http://localhost:%d\n", PORT)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sse/sseclient"
+)
+
+// TestEventsNoLossAcrossForcedDisconnect runs the real /events handler
+// behind an httptest server, subscribes to it with sseclient, and forcibly
+// severs the open connection mid-stream (as a dropped connection or proxy
+// timeout would). sseclient.Client reconnects with Last-Event-ID set to the
+// last event it saw, and the EventStore-backed replay must hand back every
+// event published while it was disconnected, so the client observes every
+// "update" event exactly once, in order, across the disconnect. (Event IDs
+// themselves aren't checked for consecutiveness: the reconnect's own
+// "connected" event also consumes one, so gaps in the ID sequence are
+// expected and harmless.)
+func TestEventsNoLossAcrossForcedDisconnect(t *testing.T) {
+	broker = NewBroker()
+	eventStore = NewMemoryEventStore()
+	eventIDCounter = 0
+
+	srv := httptest.NewServer(http.HandlerFunc(handleEvents))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := sseclient.NewClient()
+	events, errs := client.Subscribe(ctx, srv.URL)
+	go func() {
+		for range errs {
+			// Forcing the connection closed below is expected to surface an
+			// error here; draining keeps the channel's one-slot buffer free
+			// so later errors aren't dropped by the client's non-blocking send.
+		}
+	}()
+
+	waitForUpdate := func() int {
+		t.Helper()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					t.Fatal("event stream closed unexpectedly")
+				}
+				if event.Type != "update" {
+					continue
+				}
+				var payload sseclient.UpdatePayload
+				if err := json.Unmarshal([]byte(event.Data), &payload); err != nil {
+					t.Fatalf("decoding update payload %q: %v", event.Data, err)
+				}
+				return payload.Value
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for an update event")
+				return 0
+			}
+		}
+	}
+
+	broker.EmitUpdate(UpdatePayload{Timestamp: time.Now().UTC(), Value: 1, Message: "first"})
+	if got := waitForUpdate(); got != 1 {
+		t.Fatalf("got update value %d before disconnect, want 1", got)
+	}
+
+	// Sever the open connection, simulating a dropped network link. The
+	// client's run loop observes a read error and reconnects with
+	// Last-Event-ID set to the last ID it saw.
+	srv.CloseClientConnections()
+
+	// Published while the client is disconnected; both must still arrive,
+	// in order and without duplicates, via replay once it reconnects.
+	broker.EmitUpdate(UpdatePayload{Timestamp: time.Now().UTC(), Value: 2, Message: "second"})
+	broker.EmitUpdate(UpdatePayload{Timestamp: time.Now().UTC(), Value: 3, Message: "third"})
+
+	for _, want := range []int{2, 3} {
+		if got := waitForUpdate(); got != want {
+			t.Fatalf("got update value %d after reconnect, want %d (event lost, duplicated, or reordered)", got, want)
+		}
+	}
+}
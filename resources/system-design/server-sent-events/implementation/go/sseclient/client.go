@@ -0,0 +1,242 @@
+package sseclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+// Client is a reconnecting SSE consumer. A zero Client, like one from
+// NewClient, uses http.DefaultClient.
+type Client struct {
+	HTTPClient *http.Client
+
+	mu          sync.Mutex
+	lastEventID string
+	retryBase   time.Duration
+	cancel      context.CancelFunc
+	closed      bool
+
+	updateCallbacks       []func(UpdatePayload)
+	notificationCallbacks []func(NotificationPayload)
+	stockTickCallbacks    []func(StockTickPayload)
+}
+
+// NewClient returns a Client that uses http.DefaultClient.
+func NewClient() *Client {
+	return &Client{HTTPClient: http.DefaultClient}
+}
+
+// Subscribe connects to url and streams parsed events on the returned
+// channel. On any transport error it reconnects with exponential backoff
+// (initial 500ms, capped at 30s, with jitter), sending Last-Event-ID on the
+// reconnect so no events are missed, until ctx is done or Close is called.
+// Errors are reported on the returned error channel without stopping
+// reconnection. The events channel is closed when the subscription ends.
+func (c *Client) Subscribe(ctx context.Context, url string) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	go c.run(ctx, url, events, errs)
+
+	return events, errs
+}
+
+// OnUpdate registers callback to run whenever a subscribed stream delivers
+// an "update" event whose data decodes as UpdatePayload.
+func (c *Client) OnUpdate(callback func(UpdatePayload)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updateCallbacks = append(c.updateCallbacks, callback)
+}
+
+// OnNotification registers callback to run whenever a subscribed stream
+// delivers a "notification" event whose data decodes as NotificationPayload.
+func (c *Client) OnNotification(callback func(NotificationPayload)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notificationCallbacks = append(c.notificationCallbacks, callback)
+}
+
+// OnStockTick registers callback to run whenever a subscribed stream
+// delivers a "price_update" event whose data decodes as StockTickPayload.
+func (c *Client) OnStockTick(callback func(StockTickPayload)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stockTickCallbacks = append(c.stockTickCallbacks, callback)
+}
+
+// dispatchTyped decodes event.Data into the payload type registered for
+// event.Type, if any, and invokes the matching callbacks. Unknown types and
+// decode failures are silently ignored; callers that need the raw event
+// still receive it on the Subscribe channel.
+func (c *Client) dispatchTyped(event Event) {
+	switch event.Type {
+	case "update":
+		var payload UpdatePayload
+		if json.Unmarshal([]byte(event.Data), &payload) != nil {
+			return
+		}
+		c.mu.Lock()
+		callbacks := append([]func(UpdatePayload){}, c.updateCallbacks...)
+		c.mu.Unlock()
+		for _, callback := range callbacks {
+			callback(payload)
+		}
+	case "notification":
+		var payload NotificationPayload
+		if json.Unmarshal([]byte(event.Data), &payload) != nil {
+			return
+		}
+		c.mu.Lock()
+		callbacks := append([]func(NotificationPayload){}, c.notificationCallbacks...)
+		c.mu.Unlock()
+		for _, callback := range callbacks {
+			callback(payload)
+		}
+	case "price_update":
+		var payload StockTickPayload
+		if json.Unmarshal([]byte(event.Data), &payload) != nil {
+			return
+		}
+		c.mu.Lock()
+		callbacks := append([]func(StockTickPayload){}, c.stockTickCallbacks...)
+		c.mu.Unlock()
+		for _, callback := range callbacks {
+			callback(payload)
+		}
+	}
+}
+
+// Close ends the subscription and releases its resources.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+func (c *Client) run(ctx context.Context, url string, events chan<- Event, errs chan<- error) {
+	defer close(events)
+
+	backoff := c.getRetryBase()
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		connected, err := c.connectOnce(ctx, url, events)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = c.getRetryBase()
+		}
+		if err != nil {
+			select {
+			case errs <- err:
+			default:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(withJitter(backoff)):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// connectOnce opens a single HTTP connection to url, sending the last seen
+// event ID for replay, and parses the response body as an SSE stream until
+// it ends or ctx is cancelled. connected reports whether the server
+// responded with a 200 OK, which run uses to decide whether to reset the
+// backoff.
+func (c *Client) connectOnce(ctx context.Context, url string, events chan<- Event) (connected bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID := c.getLastEventID(); lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("sseclient: unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return true, readEvents(ctx, resp.Body, c, events)
+}
+
+func (c *Client) getLastEventID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastEventID
+}
+
+func (c *Client) setLastEventID(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastEventID = id
+}
+
+func (c *Client) getRetryBase() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.retryBase == 0 {
+		return initialBackoff
+	}
+	return c.retryBase
+}
+
+func (c *Client) setRetryBase(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retryBase = d
+}
+
+// withJitter randomizes d by +/-25% so that many clients reconnecting at
+// once don't all retry in lockstep.
+func withJitter(d time.Duration) time.Duration {
+	delta := time.Duration(float64(d) * 0.25)
+	if delta <= 0 {
+		return d
+	}
+	return d - delta + time.Duration(rand.Int63n(int64(2*delta)))
+}
@@ -0,0 +1,104 @@
+// Package sseclient is a reconnecting Go consumer for Server-Sent Events
+// streams, the client-side counterpart to the SSE server in the parent
+// directory.
+package sseclient
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a single parsed SSE event: the concatenation of one or more
+// data: lines, tagged with the id: and event: fields that preceded them.
+type Event struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// readEvents parses the SSE wire format from r per the spec: id:/event:/
+// data:/retry: fields, multi-line data: concatenation with "\n", comment
+// lines starting with ":" (including heartbeats), and a blank line to
+// dispatch the event accumulated so far. Parsed events are sent on events;
+// a non-empty id: updates client's Last-Event-ID, and a retry: value
+// updates client's reconnect backoff base. It returns nil on a clean EOF.
+func readEvents(ctx context.Context, r io.Reader, client *Client, events chan<- Event) error {
+	reader := bufio.NewReader(r)
+
+	var (
+		id, eventType string
+		dataLines     []string
+	)
+
+	dispatch := func() {
+		if id == "" && eventType == "" && dataLines == nil {
+			return
+		}
+		typ := eventType
+		if typ == "" {
+			typ = "message"
+		}
+		if id != "" {
+			client.setLastEventID(id)
+		}
+
+		event := Event{ID: id, Type: typ, Data: strings.Join(dataLines, "\n")}
+		client.dispatchTyped(event)
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+
+		id, eventType, dataLines = "", "", nil
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			dispatch()
+		case strings.HasPrefix(line, ":"):
+			// Comment line, used by the server for heartbeats. Ignore.
+		default:
+			field, value := splitField(line)
+			switch field {
+			case "id":
+				id = value
+			case "event":
+				eventType = value
+			case "data":
+				dataLines = append(dataLines, value)
+			case "retry":
+				if ms, convErr := strconv.Atoi(value); convErr == nil {
+					client.setRetryBase(time.Duration(ms) * time.Millisecond)
+				}
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// splitField splits an SSE wire line into its field name and value, per
+// the spec's rule that a single space after the colon (if present) is not
+// part of the value.
+func splitField(line string) (field, value string) {
+	idx := strings.IndexByte(line, ':')
+	if idx == -1 {
+		return line, ""
+	}
+	field = line[:idx]
+	value = strings.TrimPrefix(line[idx+1:], " ")
+	return field, value
+}
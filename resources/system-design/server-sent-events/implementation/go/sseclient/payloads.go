@@ -0,0 +1,30 @@
+package sseclient
+
+import "time"
+
+// These payload types mirror the server's typed event payloads (see
+// payloads.go in the parent package). The client and server only share a
+// wire format, not Go types, so the shapes are kept in sync by hand.
+
+// UpdatePayload is the payload of an "update" event.
+type UpdatePayload struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     int       `json:"value"`
+	Message   string    `json:"message"`
+}
+
+// NotificationPayload is the payload of a "notification" event.
+type NotificationPayload struct {
+	UserID    string    `json:"user_id"`
+	Type      string    `json:"type"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// StockTickPayload is the payload of a "price_update" event.
+type StockTickPayload struct {
+	Symbol    string    `json:"symbol"`
+	Price     float64   `json:"price"`
+	Change    float64   `json:"change"`
+	Timestamp time.Time `json:"timestamp"`
+}